@@ -0,0 +1,82 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// inFlight tracks downloads currently running in this process, keyed by
+// cache filename, so that concurrent goroutines requesting the same url
+// share a single download instead of racing on the same temp file.
+var (
+	inFlightMu sync.Mutex
+	inFlight   = map[string]*call{}
+)
+
+// call represents an in-flight (or just completed) download for a single key.
+type call struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// singleflight runs fn for key, ensuring that only one goroutine in this
+// process executes fn for a given key at a time. Concurrent callers for the
+// same key block until the original call completes and receive its result.
+func singleflight(key string, fn func() error) error {
+	inFlightMu.Lock()
+	if c, ok := inFlight[key]; ok {
+		inFlightMu.Unlock()
+		c.wg.Wait()
+		return c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	inFlight[key] = c
+	inFlightMu.Unlock()
+
+	c.err = fn()
+	c.wg.Done()
+
+	inFlightMu.Lock()
+	delete(inFlight, key)
+	inFlightMu.Unlock()
+
+	return c.err
+}
+
+// fileLock is a cross-process advisory lock backed by flock(2) on a sibling
+// ".lock" file, preventing two colima invocations from downloading the same
+// url at once.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile acquires an exclusive, blocking flock on path+".lock", creating
+// the lock file if it does not exist. The lock is released with unlock.
+func lockFile(path string) (*fileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error preparing lock dir: %w", err)
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error acquiring lock: %w", err)
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// unlock releases the lock and closes the underlying file.
+func (l *fileLock) unlock() error {
+	defer l.f.Close()
+	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+}
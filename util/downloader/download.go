@@ -1,6 +1,8 @@
 package downloader
 
 import (
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"net"
@@ -8,8 +10,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/abiosoft/colima/config"
@@ -24,10 +28,45 @@ type (
 	guestActions = environment.GuestActions
 )
 
+// defaults for chunked, concurrent downloads.
+const (
+	defaultMinChunkSize   = 16 * 1024 * 1024 // 16 MiB
+	defaultMaxConcurrency = 4
+)
+
 // Request is download request
 type Request struct {
 	URL string // request URL
-	SHA *SHA   // shasum url
+	SHA *SHA   // optional checksum verification
+
+	// MaxConcurrency is the maximum number of chunks fetched concurrently
+	// when the server supports ranged requests. Defaults to 4 when unset.
+	MaxConcurrency int
+	// MinChunkSize is the smallest chunk size considered for a chunked
+	// download. Files smaller than MinChunkSize*2 are downloaded as a
+	// single stream. Defaults to 16 MiB when unset.
+	MinChunkSize int64
+
+	// Signature, if set, is verified against Roots once the SHA check
+	// passes, allowing Colima to trust its own rotating signing keys
+	// rather than TLS alone.
+	Signature *Signature
+	// Roots is the set of trusted root public keys used to verify Signature.
+	Roots []ed25519.PublicKey
+}
+
+func (r Request) maxConcurrency() int {
+	if r.MaxConcurrency > 0 {
+		return r.MaxConcurrency
+	}
+	return defaultMaxConcurrency
+}
+
+func (r Request) minChunkSize() int64 {
+	if r.MinChunkSize > 0 {
+		return r.MinChunkSize
+	}
+	return defaultMinChunkSize
 }
 
 // DownloadToGuest downloads file at url and saves it in the destination.
@@ -50,16 +89,37 @@ func DownloadToGuest(host hostActions, guest guestActions, log *logrus.Logger, r
 }
 
 // Download downloads file at url and returns the location of the downloaded file.
+//
+// Concurrent calls for the same url, whether from goroutines in this process or from
+// separate colima invocations, are coordinated so that only one download happens and
+// every caller observes the same result.
 func Download(host hostActions, log *logrus.Logger, r Request) (string, error) {
 	d := downloader{
 		host: host,
 		log:  log,
 	}
 
-	if !d.hasCache(r.URL) {
-		if err := d.downloadFile(r); err != nil {
-			return "", fmt.Errorf("error downloading '%s': %w", r.URL, err)
+	err := singleflight(CacheFilename(r.URL), func() error {
+		if d.hasCache(r.URL) {
+			return nil
 		}
+
+		lock, err := lockFile(CacheFilename(r.URL))
+		if err != nil {
+			return fmt.Errorf("error acquiring download lock: %w", err)
+		}
+		defer lock.unlock()
+
+		// re-check now that the cross-process lock is held, another colima
+		// invocation may have completed the download while we were waiting.
+		if d.hasCache(r.URL) {
+			return nil
+		}
+
+		return d.downloadFile(r)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error downloading '%s': %w", r.URL, err)
 	}
 
 	return CacheFilename(r.URL), nil
@@ -75,8 +135,76 @@ func CacheFilename(url string) string {
 	return filepath.Join(config.CacheDir(), "caches", shautil.SHA256(url).String())
 }
 
-func (d downloader) cacheDownloadingFileName(url string) string {
-	return CacheFilename(url) + ".downloading"
+// partFilename returns the well-known location of a resumable partial
+// download for url, following git-lfs's naming convention. Unlike the
+// in-flight temp file, this name is fixed so a later attempt can find and
+// adopt it.
+func (d downloader) partFilename(url string) string {
+	return CacheFilename(url) + ".part"
+}
+
+// prepareDownloadingFile opens a uniquely named, freshly created temp file
+// for downloading url into, adopting any resumable .part file left behind
+// by a previous attempt. See adoptOrCreateTemp for the adoption logic. The
+// returned bool reports whether the file's contents came from an adopted
+// .part file (true) or are a brand new, zero-filled temp file (false) —
+// callers must not trust anything recorded against the old data (e.g. a
+// chunk completion marker) unless this is true.
+//
+// A leftover randomly-named .downloading file for url indicates a previous
+// attempt was killed before it could rename its temp file to .part; such a
+// file is orphaned and unrecoverable (its offsets are unknown), so this
+// only logs its presence rather than adopting or deleting it.
+func (d downloader) prepareDownloadingFile(url string) (*os.File, string, bool, error) {
+	dir := filepath.Dir(CacheFilename(url))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", false, fmt.Errorf("error preparing cache dir: %w", err)
+	}
+
+	pattern := shautil.SHA256(url).String() + "-*.downloading"
+	if leftover, _ := filepath.Glob(filepath.Join(dir, pattern)); len(leftover) > 0 {
+		d.log.Tracef("found orphaned temp file(s) for %s from a prior crashed attempt: %v", url, leftover)
+	}
+
+	return adoptOrCreateTemp(dir, pattern, d.partFilename(url))
+}
+
+// adoptOrCreateTemp creates a uniquely named temp file matching pattern in
+// dir. If a file exists at partPath, it is atomically adopted onto the temp
+// path (renamed) so the caller can resume from it; since rename is atomic,
+// at most one caller racing for the same partPath wins it, and everyone
+// else keeps their own empty temp file and starts from zero rather than
+// sharing a partially-written base. The returned bool reports whether the
+// part file was adopted.
+func adoptOrCreateTemp(dir, pattern, partPath string) (*os.File, string, bool, error) {
+	tmp, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("error creating destination file: %w", err)
+	}
+	filename := tmp.Name()
+
+	if err := os.Rename(partPath, filename); err == nil {
+		_ = tmp.Close()
+		f, err := os.OpenFile(filename, os.O_RDWR, 0644)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("error opening adopted partial file: %w", err)
+		}
+		return f, filename, true, nil
+	}
+
+	return tmp, filename, false, nil
+}
+
+// httpClient is the client used for all download related requests, tuned to
+// avoid indefinite hangs on slow or stalled connections.
+func httpClient() *http.Client {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: 30 * time.Second,
+	}
+	return &http.Client{Transport: transport}
 }
 
 func (d downloader) downloadFile(r Request) (err error) {
@@ -84,22 +212,262 @@ func (d downloader) downloadFile(r Request) (err error) {
 
 	// save to a temporary file initially before renaming to the desired file after successful download
 	// this prevents having a corrupt file
-	cacheDownloadingFilename := d.cacheDownloadingFileName(r.URL)
-	if err := os.MkdirAll(filepath.Dir(cacheDownloadingFilename), 0755); err != nil {
-		err = fmt.Errorf("error preparing cache dir: %w", err)
+	destFile, tempFilename, adopted, err := d.prepareDownloadingFile(r.URL)
+	if err != nil {
 		d.log.Tracef("error downloading %s: %v", r.URL, err)
 		return err
 	}
+	defer destFile.Close()
 
-	// create file, or open if it exists
-	destFile, err := os.OpenFile(cacheDownloadingFilename, os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		err = fmt.Errorf("error creating destination file: %w", err)
-		d.log.Tracef("error downloading %s: %v", r.URL, err)
+	defer func() {
+		if err != nil {
+			// preserve the partial file under its well-known name so a future attempt can resume it
+			_ = os.Rename(tempFilename, d.partFilename(r.URL))
+		}
+	}()
+
+	if size, ok := d.rangeSupport(r.URL); ok {
+		if chunks := r.chunkCount(size); chunks > 1 {
+			d.log.Tracef("downloading %s in %d chunks", r.URL, chunks)
+			if err = d.downloadFileChunked(r, destFile, size, chunks, adopted); err != nil {
+				d.log.Tracef("error downloading %s: %v", r.URL, err)
+				return err
+			}
+			return d.finalizeDownload(r, tempFilename)
+		}
+	}
+
+	if err = d.downloadFileSingle(r, destFile); err != nil {
 		return err
 	}
-	defer destFile.Close()
+	return d.finalizeDownload(r, tempFilename)
+}
+
+// chunkCount returns the number of chunks a file of the given size should be
+// split into, bound by the request's MaxConcurrency. A result of 1 or less
+// means the file should be downloaded as a single stream.
+func (r Request) chunkCount(size int64) int {
+	minChunkSize := r.minChunkSize()
+	if size < minChunkSize*2 {
+		return 1
+	}
 
+	chunks := int(size / minChunkSize)
+	if max := r.maxConcurrency(); chunks > max {
+		chunks = max
+	}
+	return chunks
+}
+
+// rangeSupport checks if url supports ranged requests, returning the size of
+// the resource when it does.
+func (d downloader) rangeSupport(url string) (size int64, ok bool) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, false
+	}
+	if !strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return 0, false
+	}
+	if resp.ContentLength <= 0 {
+		return 0, false
+	}
+
+	return resp.ContentLength, true
+}
+
+// downloadFileChunked downloads r.URL into destFile using multiple
+// concurrent ranged GET requests, writing each chunk directly at its offset
+// via WriteAt. When destFile was adopted from a previous attempt's .part
+// file, chunks already recorded as complete (see chunksFilename) are
+// skipped rather than re-fetched, so resume actually works; for a fresh
+// temp file the marker cannot correspond to destFile's (zero) contents and
+// is discarded instead of trusted. On error the file and completion marker
+// are left in place so a subsequent attempt can resume.
+func (d downloader) downloadFileChunked(r Request, destFile *os.File, size int64, chunks int, adopted bool) error {
+	if err := destFile.Truncate(size); err != nil {
+		return fmt.Errorf("error allocating destination file: %w", err)
+	}
+
+	chunkSize := size / int64(chunks)
+	starts := make([]int64, chunks)
+	ends := make([]int64, chunks)
+	for i := 0; i < chunks; i++ {
+		starts[i] = int64(i) * chunkSize
+		ends[i] = starts[i] + chunkSize - 1
+		if i == chunks-1 {
+			ends[i] = size - 1
+		}
+	}
+
+	var done map[int]bool
+	if adopted {
+		done = d.completedChunks(r.URL)
+	} else {
+		done = make(map[int]bool)
+		if _, err := os.Stat(d.chunksFilename(r.URL)); err == nil {
+			// a marker from a run that crashed before its temp file could be
+			// renamed to .part; destFile is fresh and zero-filled, so these
+			// recorded completions no longer correspond to anything on disk
+			d.log.Tracef("discarding stale chunk marker for %s", r.URL)
+			_ = os.Remove(d.chunksFilename(r.URL))
+		}
+	}
+
+	var resumed int64
+	for i := range starts {
+		if done[i] {
+			resumed += ends[i] - starts[i] + 1
+		}
+	}
+	if resumed > 0 {
+		d.log.Tracef("resuming %s, %d/%d chunks already downloaded", r.URL, len(done), chunks)
+	}
+
+	progress := newProgress(d.log, size, resumed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var errOnce sync.Once
+
+	for i := 0; i < chunks; i++ {
+		if done[i] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+
+			if err := d.downloadChunk(ctx, r.URL, destFile, start, end, progress); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			if err := d.markChunkComplete(r.URL, i); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i, starts[i], ends[i])
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("error during chunked download: %w", firstErr)
+	}
+
+	// the whole file is now assembled; the marker has served its purpose
+	_ = os.Remove(d.chunksFilename(r.URL))
+	return nil
+}
+
+// chunksFilename returns the well-known location of the per-chunk
+// completion marker for a chunked download of url, mirroring partFilename
+// so it survives alongside the adopted .part file across attempts.
+func (d downloader) chunksFilename(url string) string {
+	return d.partFilename(url) + ".chunks"
+}
+
+// completedChunks returns the set of chunk indices already fully written
+// for url, as recorded by markChunkComplete in a previous attempt. A
+// missing or unreadable marker file simply yields no completed chunks.
+func (d downloader) completedChunks(url string) map[int]bool {
+	done := make(map[int]bool)
+
+	b, err := os.ReadFile(d.chunksFilename(url))
+	if err != nil {
+		return done
+	}
+
+	for _, field := range strings.Fields(string(b)) {
+		i, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		done[i] = true
+	}
+	return done
+}
+
+// markChunkComplete records that chunk i of url has been fully written, so
+// a later attempt can skip re-fetching it.
+func (d downloader) markChunkComplete(url string, i int) error {
+	f, err := os.OpenFile(d.chunksFilename(url), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error recording chunk progress: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%d\n", i)
+	return err
+}
+
+// downloadChunk fetches the byte range [start, end] of url and writes it to
+// f at the matching offset, advancing progress as bytes are written.
+func (d downloader) downloadChunk(ctx context.Context, url string, f *os.File, start, end int64, progress *Progress) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("error during chunk download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("unexpected status code for ranged request: %d", resp.StatusCode)
+	}
+
+	w := &offsetWriter{f: f, offset: start, progress: progress}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error writing chunk to file: %w", err)
+	}
+
+	return nil
+}
+
+// offsetWriter writes sequential Write calls into f starting at offset,
+// advancing progress with each write.
+type offsetWriter struct {
+	f        *os.File
+	offset   int64
+	progress *Progress
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	if n > 0 {
+		w.progress.add(int64(n))
+	}
+	return n, err
+}
+
+// downloadFileSingle downloads r.URL into destFile as a single stream,
+// resuming from destFile's current size when possible. This is the
+// fallback path for servers that do not support ranged requests.
+func (d downloader) downloadFileSingle(r Request, destFile *os.File) (err error) {
 	// check file size to resume download
 	stat, err := destFile.Stat()
 	if err != nil {
@@ -120,14 +488,7 @@ func (d downloader) downloadFile(r Request) (err error) {
 		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", currentSize))
 	}
 
-	// custom transport to avoid timeout on slow connections
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout: 30 * time.Second,
-		}).DialContext,
-		ResponseHeaderTimeout: 30 * time.Second,
-	}
-	client := &http.Client{Transport: transport}
+	client := httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		err = fmt.Errorf("error during download: %w", err)
@@ -178,26 +539,38 @@ func (d downloader) downloadFile(r Request) (err error) {
 		return err
 	}
 
-	// validate download if sha is present
+	return nil
+}
+
+// finalizeDownload validates the downloaded file against r.SHA and
+// r.Signature (if present) and promotes it into the cache. A validation
+// failure deletes the file rather than preserving it: a corrupt resume
+// base is worse than none.
+func (d downloader) finalizeDownload(r Request, tempFilename string) error {
 	if r.SHA != nil {
-		if err := r.SHA.validateDownload(d.host, r.URL, cacheDownloadingFilename); err != nil {
-			// move file to allow subsequent re-download
-			// error discarded, would not be actioned anyways
-			_ = os.Rename(cacheDownloadingFilename, cacheDownloadingFilename+".invalid")
+		if err := r.SHA.validateDownload(r.URL, tempFilename); err != nil {
+			_ = os.Remove(tempFilename)
 			err = fmt.Errorf("error validating SHA sum for '%s': %w", path.Base(r.URL), err)
 			d.log.Tracef("error downloading %s: %v", r.URL, err)
 			return err
 		}
 	}
 
+	if err := d.verifySignature(r, tempFilename); err != nil {
+		_ = os.Remove(tempFilename)
+		err = fmt.Errorf("error verifying signature for '%s': %w", path.Base(r.URL), err)
+		d.log.Tracef("error downloading %s: %v", r.URL, err)
+		return err
+	}
+
 	d.log.Tracef("downloaded %s", r.URL)
-	return os.Rename(cacheDownloadingFilename, CacheFilename(r.URL))
+	return os.Rename(tempFilename, CacheFilename(r.URL))
 }
 
 // Progress tracks download progress.
 type Progress struct {
 	Total      int64 // total size
-	Current    int64 // downloaded size
+	Current    int64 // downloaded size, updated atomically
 	mu         sync.Mutex
 	lastReport time.Time
 	logger     *logrus.Logger
@@ -214,21 +587,26 @@ func newProgress(logger *logrus.Logger, total, current int64) *Progress {
 
 // Write implements io.Writer.
 func (p *Progress) Write(b []byte) (int, error) {
-	n := len(b)
+	p.add(int64(len(b)))
+	return len(b), nil
+}
+
+// add advances the current progress by n bytes. It is safe to call
+// concurrently, e.g. from multiple chunk downloads sharing one Progress.
+func (p *Progress) add(n int64) {
+	current := atomic.AddInt64(&p.Current, n)
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.Current += int64(n)
-
 	// efficient not to report on every write
 	if time.Since(p.lastReport) < (time.Second / 2) {
-		return n, nil
+		return
 	}
 
 	p.lastReport = time.Now()
 	// no new line
-	fmt.Printf("\rdownloading ... %s ", terminal.Progress(p.Current, p.Total))
-	return n, nil
+	fmt.Printf("\rdownloading ... %s ", terminal.Progress(current, p.Total))
 }
 
 func (d downloader) hasCache(url string) bool {
@@ -0,0 +1,291 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSingleflight(t *testing.T) {
+	const goroutines = 50
+	key := "https://example.com/image.tar.gz"
+
+	var calls int32
+	var started sync.WaitGroup
+	var wg sync.WaitGroup
+	errs := make([]error, goroutines)
+
+	started.Add(1)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = singleflight(key, func() error {
+				atomic.AddInt32(&calls, 1)
+				// hold the in-flight call open long enough for the other
+				// goroutines to queue up behind it before it completes
+				started.Done()
+				time.Sleep(50 * time.Millisecond)
+				return fmt.Errorf("boom")
+			})
+		}(i)
+	}
+	started.Wait()
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("expected exactly 1 call, got %d", calls)
+	}
+	for i, err := range errs {
+		if err == nil || err.Error() != "boom" {
+			t.Fatalf("caller %d: expected shared error 'boom', got %v", i, err)
+		}
+	}
+}
+
+func TestSingleflightDistinctKeys(t *testing.T) {
+	var calls int32
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := singleflight(key, func() error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 calls for 3 distinct keys, got %d", calls)
+	}
+}
+
+func TestAdoptOrCreateTempAdoptsExistingPart(t *testing.T) {
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "abc.part")
+	if err := os.WriteFile(partPath, []byte("partial content"), 0644); err != nil {
+		t.Fatalf("error seeding part file: %v", err)
+	}
+
+	f, filename, adopted, err := adoptOrCreateTemp(dir, "abc-*.downloading", partPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	if !adopted {
+		t.Fatalf("expected adopted to be true")
+	}
+
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Fatalf("expected part file to be renamed away, stat err: %v", err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error reading adopted file: %v", err)
+	}
+	if string(got) != "partial content" {
+		t.Fatalf("expected adopted content %q, got %q", "partial content", got)
+	}
+}
+
+func TestAdoptOrCreateTempStartsFreshWithoutPart(t *testing.T) {
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "abc.part")
+
+	f, filename, adopted, err := adoptOrCreateTemp(dir, "abc-*.downloading", partPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	if adopted {
+		t.Fatalf("expected adopted to be false")
+	}
+
+	stat, err := os.Stat(filename)
+	if err != nil {
+		t.Fatalf("error stating temp file: %v", err)
+	}
+	if stat.Size() != 0 {
+		t.Fatalf("expected fresh temp file to be empty, got size %d", stat.Size())
+	}
+}
+
+// TestAdoptOrCreateTempRace spawns goroutines racing to adopt the same
+// .part file. Exactly one must win the rename and see the partial content;
+// everyone else must start from an empty temp file, and no content is lost
+// or duplicated.
+func TestAdoptOrCreateTempRace(t *testing.T) {
+	const goroutines = 20
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "abc.part")
+	if err := os.WriteFile(partPath, []byte("partial content"), 0644); err != nil {
+		t.Fatalf("error seeding part file: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var winners int32
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			f, filename, adopted, err := adoptOrCreateTemp(dir, "abc-*.downloading", partPath)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer f.Close()
+
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				t.Errorf("error reading temp file: %v", err)
+				return
+			}
+			if string(content) == "partial content" {
+				if !adopted {
+					t.Errorf("expected adopted to be true for the winning goroutine")
+				}
+				atomic.AddInt32(&winners, 1)
+			} else if len(content) != 0 {
+				t.Errorf("expected empty or fully adopted content, got %q", content)
+			} else if adopted {
+				t.Errorf("expected adopted to be false for a losing goroutine")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if winners != 1 {
+		t.Fatalf("expected exactly 1 goroutine to adopt the part file, got %d", winners)
+	}
+}
+
+// TestDownloadSingleFetchAcrossGoroutines races many goroutines calling
+// Download for the same URL and asserts the content is fetched from the
+// network exactly once, with every caller observing the correct file.
+func TestDownloadSingleFetchAcrossGoroutines(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const want = "the quick brown fox jumps over the lazy dog"
+	var fetches int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&fetches, 1)
+		}
+		_, _ = w.Write([]byte(want))
+	}))
+	defer srv.Close()
+
+	const goroutines = 20
+	req := Request{URL: srv.URL}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	var wg sync.WaitGroup
+	filenames := make([]string, goroutines)
+	errs := make([]error, goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			filenames[i], errs[i] = Download(nil, log, req)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("goroutine %d: unexpected error: %v", i, err)
+		}
+
+		got, err := os.ReadFile(filenames[i])
+		if err != nil {
+			t.Fatalf("goroutine %d: error reading downloaded file: %v", i, err)
+		}
+		if string(got) != want {
+			t.Fatalf("goroutine %d: expected content %q, got %q", i, want, got)
+		}
+	}
+
+	if fetches != 1 {
+		t.Fatalf("expected exactly 1 network fetch across %d goroutines, got %d", goroutines, fetches)
+	}
+}
+
+// TestDownloadFileChunkedIgnoresStaleMarkerWhenNotAdopted simulates a crash
+// that leaves a chunk completion marker behind without ever producing an
+// adopted .part file (the process was killed before the rename that would
+// have preserved it). A fresh, non-adopted destination file must not trust
+// that marker, or it would skip chunks that are actually all-zero.
+func TestDownloadFileChunkedIgnoresStaleMarkerWhenNotAdopted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const chunkPayload = "AAAAAAAABBBBBBBB" // two 8-byte chunks
+	var gets int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+
+		var start, end int
+		_, _ = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(chunkPayload)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(chunkPayload[start : end+1]))
+	}))
+	defer srv.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	d := downloader{log: log}
+
+	if err := os.MkdirAll(filepath.Dir(d.chunksFilename(srv.URL)), 0755); err != nil {
+		t.Fatalf("error preparing cache dir: %v", err)
+	}
+	// seed a stale completion marker claiming chunk 0 is already done, as a
+	// crashed prior attempt would have left behind without an adopted .part
+	if err := os.WriteFile(d.chunksFilename(srv.URL), []byte("0\n"), 0644); err != nil {
+		t.Fatalf("error seeding stale marker: %v", err)
+	}
+
+	destFile, err := os.CreateTemp(t.TempDir(), "dest-*.downloading")
+	if err != nil {
+		t.Fatalf("error creating dest file: %v", err)
+	}
+	defer destFile.Close()
+
+	if err := d.downloadFileChunked(Request{URL: srv.URL}, destFile, int64(len(chunkPayload)), 2, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gets != 2 {
+		t.Fatalf("expected both chunks to be fetched since the stale marker must be discarded, got %d GETs", gets)
+	}
+
+	got, err := os.ReadFile(destFile.Name())
+	if err != nil {
+		t.Fatalf("error reading dest file: %v", err)
+	}
+	if string(got) != chunkPayload {
+		t.Fatalf("expected content %q, got %q", chunkPayload, got)
+	}
+
+	if _, err := os.Stat(d.chunksFilename(srv.URL)); !os.IsNotExist(err) {
+		t.Fatalf("expected stale marker to be removed, stat err: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/abiosoft/colima/util/terminal"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultMaxConcurrentFiles is the default cap on how many Requests in a
+// Manifest download at once.
+const defaultMaxConcurrentFiles = 20
+
+// Manifest is a set of download requests fetched together under a shared
+// global concurrency cap, in addition to each Request's own MaxConcurrency
+// for chunked transfers within a single file.
+type Manifest []Request
+
+// ManifestOptions configures a Manifest download.
+type ManifestOptions struct {
+	// MaxConcurrentFiles bounds how many Requests in the Manifest download at
+	// once. Defaults to 20 when unset.
+	MaxConcurrentFiles int
+}
+
+func (o ManifestOptions) maxConcurrentFiles() int {
+	if o.MaxConcurrentFiles > 0 {
+		return o.MaxConcurrentFiles
+	}
+	return defaultMaxConcurrentFiles
+}
+
+// Result is the outcome of downloading a single Request from a Manifest.
+type Result struct {
+	Request  Request
+	Filename string
+	Err      error
+}
+
+// DownloadAll downloads every Request in m concurrently, bound by
+// opts.MaxConcurrentFiles, and returns one Result per Request in Manifest
+// order.
+func (m Manifest) DownloadAll(ctx context.Context, host hostActions, log *logrus.Logger, opts ManifestOptions) []Result {
+	results := make([]Result, len(m))
+	progress := newManifestProgress(log, m)
+
+	sem := make(chan struct{}, opts.maxConcurrentFiles())
+	var wg sync.WaitGroup
+
+	for i, r := range m {
+		i, r := i, r
+
+		if err := ctx.Err(); err != nil {
+			results[i] = Result{Request: r, Err: err}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			progress.starting(r)
+			filename, err := Download(host, log, r)
+			progress.finished(r, err)
+
+			results[i] = Result{Request: r, Filename: filename, Err: err}
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// DownloadToGuestAll downloads every Request in m to the host cache, then
+// copies each into destDir (an absolute guest directory that does not
+// require root access) on guest.
+func (m Manifest) DownloadToGuestAll(ctx context.Context, host hostActions, guest guestActions, log *logrus.Logger, destDir string, opts ManifestOptions) []Result {
+	results := m.DownloadAll(ctx, host, log, opts)
+
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+
+		dest := path.Join(destDir, path.Base(res.Request.URL))
+		if err := guest.RunQuiet("cp", res.Filename, dest); err != nil {
+			results[i].Err = fmt.Errorf("error copying '%s' to guest: %w", res.Request.URL, err)
+		}
+	}
+
+	return results
+}
+
+// manifestProgress reports the status of every file in a Manifest download.
+// When a terminal is attached it redraws a multi-line status block in
+// place; otherwise it logs one line for a file each time its status
+// changes, with no lines in between. Requests
+// are tracked by URL rather than by display name, since two Requests can
+// share a basename (e.g. the same kernel filename fetched for different
+// architectures or versions).
+type manifestProgress struct {
+	log        *logrus.Logger
+	mu         sync.Mutex
+	order      []string          // request URLs, in Manifest order
+	labels     map[string]string // url -> display label
+	status     map[string]string // url -> status
+	isTerminal bool
+	rendered   bool
+}
+
+func newManifestProgress(log *logrus.Logger, m Manifest) *manifestProgress {
+	p := &manifestProgress{
+		log:        log,
+		labels:     make(map[string]string, len(m)),
+		status:     make(map[string]string, len(m)),
+		isTerminal: terminal.IsTerminal(),
+	}
+
+	for _, r := range m {
+		p.order = append(p.order, r.URL)
+		p.labels[r.URL] = path.Base(r.URL)
+		p.status[r.URL] = "pending"
+	}
+
+	if p.isTerminal {
+		p.render()
+	}
+
+	return p
+}
+
+func (p *manifestProgress) starting(r Request) {
+	p.set(r.URL, "downloading")
+}
+
+func (p *manifestProgress) finished(r Request, err error) {
+	if err != nil {
+		p.set(r.URL, "failed")
+		return
+	}
+	p.set(r.URL, "done")
+}
+
+func (p *manifestProgress) set(url, status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status[url] = status
+
+	if p.isTerminal {
+		p.render()
+		return
+	}
+
+	p.log.Infof("%s: %s", p.labels[url], status)
+}
+
+// render redraws the full status block. Callers must hold p.mu, except for
+// the initial call from newManifestProgress before any goroutine starts.
+func (p *manifestProgress) render() {
+	if p.rendered {
+		terminal.ClearLines(len(p.order))
+	}
+	p.rendered = true
+
+	for _, url := range p.order {
+		fmt.Printf("%s: %s\n", p.labels[url], p.status[url])
+	}
+}
@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TestDownloadAllRespectsMaxConcurrentFiles spawns a Manifest with more
+// requests than MaxConcurrentFiles and asserts the number of downloads
+// in flight at once never exceeds the configured cap.
+func TestDownloadAllRespectsMaxConcurrentFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const files = 10
+	const maxConcurrent = 3
+
+	var inflight int32
+	var maxObserved int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		for {
+			max := atomic.LoadInt32(&maxObserved)
+			if n <= max || atomic.CompareAndSwapInt32(&maxObserved, max, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		atomic.AddInt32(&inflight, -1)
+		_, _ = w.Write([]byte(r.URL.Query().Get("i")))
+	}))
+	defer srv.Close()
+
+	var m Manifest
+	for i := 0; i < files; i++ {
+		m = append(m, Request{URL: fmt.Sprintf("%s/?i=%d", srv.URL, i)})
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	results := m.DownloadAll(context.Background(), nil, log, ManifestOptions{MaxConcurrentFiles: maxConcurrent})
+
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, res.Err)
+		}
+	}
+
+	if maxObserved > maxConcurrent {
+		t.Fatalf("expected at most %d concurrent downloads, observed %d", maxConcurrent, maxObserved)
+	}
+}
+
+// TestDownloadAllResultOrderMatchesManifestOrder has later requests finish
+// before earlier ones and asserts the returned Results are still in
+// Manifest order, each holding its own matching Request and content.
+func TestDownloadAllResultOrderMatchesManifestOrder(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const files = 8
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		_, _ = fmt.Sscanf(r.URL.Query().Get("i"), "%d", &n)
+		// later requests finish first, so completion order is reversed
+		// relative to Manifest order
+		time.Sleep(time.Duration(files-n) * 5 * time.Millisecond)
+		_, _ = w.Write([]byte(r.URL.Query().Get("i")))
+	}))
+	defer srv.Close()
+
+	var m Manifest
+	for i := 0; i < files; i++ {
+		m = append(m, Request{URL: fmt.Sprintf("%s/?i=%d", srv.URL, i)})
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	results := m.DownloadAll(context.Background(), nil, log, ManifestOptions{})
+
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("result %d: unexpected error: %v", i, res.Err)
+		}
+		if res.Request.URL != m[i].URL {
+			t.Fatalf("result %d: expected request URL %q, got %q", i, m[i].URL, res.Request.URL)
+		}
+
+		want := fmt.Sprintf("%d", i)
+		got, err := os.ReadFile(res.Filename)
+		if err != nil {
+			t.Fatalf("result %d: error reading downloaded file: %v", i, err)
+		}
+		if string(got) != want {
+			t.Fatalf("result %d: expected content %q, got %q", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"lukechampine.com/blake3"
+)
+
+// Digest pins the expected checksum of a downloaded file under a named
+// hashing algorithm.
+type Digest struct {
+	Algorithm string // one of md5, sha1, sha256, sha512, blake3. Defaults to sha256.
+	Value     string // hex-encoded expected digest
+}
+
+func (d Digest) algorithm() string {
+	if d.Algorithm == "" {
+		return "sha256"
+	}
+	return strings.ToLower(d.Algorithm)
+}
+
+func (d Digest) newHash() (hash.Hash, error) {
+	switch d.algorithm() {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm '%s'", d.Algorithm)
+	}
+}
+
+// SHA describes how to verify the integrity of a downloaded file.
+//
+// A digest may be pinned directly via the embedded Digest's Algorithm/Value,
+// or fetched on demand from a sidecar URL (e.g. an upstream *.sha256sum
+// file). Alternates allows pinning additional digests, e.g. a locally
+// pinned sha512 alongside an upstream-published sha256.
+type SHA struct {
+	Digest              // primary digest
+	URL        string   // optional url to fetch Value from when it is empty
+	Alternates []Digest // optional additional digests that must also match
+}
+
+// digests resolves every digest that must be validated for downloadURL,
+// fetching the primary digest's Value from URL when it isn't already pinned.
+func (s SHA) digests(downloadURL string) ([]Digest, error) {
+	digests := make([]Digest, 0, 1+len(s.Alternates))
+
+	primary := s.Digest
+	if primary.Value == "" && s.URL != "" {
+		value, err := fetchDigest(s.URL, downloadURL)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching digest from '%s': %w", s.URL, err)
+		}
+		primary.Value = value
+	}
+	if primary.Value != "" {
+		digests = append(digests, primary)
+	}
+
+	for _, alt := range s.Alternates {
+		if alt.Value != "" {
+			digests = append(digests, alt)
+		}
+	}
+
+	return digests, nil
+}
+
+// validateDownload verifies file against every digest pinned by s, streaming
+// the file once through an io.MultiWriter over the selected hash implementations.
+func (s SHA) validateDownload(downloadURL, file string) error {
+	digests, err := s.digests(downloadURL)
+	if err != nil {
+		return err
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error opening file for validation: %w", err)
+	}
+	defer f.Close()
+
+	hashes := make([]hash.Hash, len(digests))
+	writers := make([]io.Writer, len(digests))
+	for i, d := range digests {
+		h, err := d.newHash()
+		if err != nil {
+			return err
+		}
+		hashes[i] = h
+		writers[i] = h
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return fmt.Errorf("error reading file for validation: %w", err)
+	}
+
+	for i, d := range digests {
+		actual := hex.EncodeToString(hashes[i].Sum(nil))
+		if !strings.EqualFold(actual, d.Value) {
+			return fmt.Errorf("%s mismatch: expected '%s', got '%s'", d.algorithm(), d.Value, actual)
+		}
+	}
+
+	return nil
+}
+
+// fetchDigest downloads a (possibly multi-line, sha256sum-style) digest file
+// from shaURL and returns the digest matching the basename of downloadURL.
+func fetchDigest(shaURL, downloadURL string) (string, error) {
+	client := httpClient()
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		req, reqErr := http.NewRequest(http.MethodGet, shaURL, nil)
+		if reqErr != nil {
+			return "", reqErr
+		}
+		resp, err = client.Do(req)
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Second * time.Duration(attempt+1))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error downloading digest file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading digest file: %w", err)
+	}
+
+	return parseDigestFile(string(body), path.Base(downloadURL))
+}
+
+// parseDigestFile parses a sha256sum-style file (one "<digest>  <filename>"
+// pair per line, optionally prefixed with a '*' for binary mode) and returns
+// the digest matching basename. A file containing a single bare digest with
+// no filename is returned unconditionally.
+func parseDigestFile(content, basename string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+
+	if len(lines) == 1 {
+		if fields := strings.Fields(lines[0]); len(fields) == 1 {
+			return fields[0], nil
+		}
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if digest, name := fields[0], strings.TrimPrefix(fields[1], "*"); name == basename {
+			return digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("no digest found for '%s'", basename)
+}
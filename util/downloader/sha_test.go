@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestParseDigestFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		basename string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "bare single digest",
+			content:  "abc123\n",
+			basename: "image.tar.gz",
+			want:     "abc123",
+		},
+		{
+			name:     "sha256sum style match",
+			content:  "deadbeef  image.tar.gz\nfeedface  other.tar.gz\n",
+			basename: "image.tar.gz",
+			want:     "deadbeef",
+		},
+		{
+			name:     "binary mode prefix is stripped from the filename",
+			content:  "deadbeef *image.tar.gz\n",
+			basename: "image.tar.gz",
+			want:     "deadbeef",
+		},
+		{
+			name:     "no matching basename",
+			content:  "deadbeef  other.tar.gz\n",
+			basename: "image.tar.gz",
+			wantErr:  true,
+		},
+		{
+			name:     "empty file",
+			content:  "",
+			basename: "image.tar.gz",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDigestFile(tt.content, tt.basename)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got digest %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected digest %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFetchDigest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("deadbeef  image.tar.gz\nfeedface  other.tar.gz\n"))
+	}))
+	defer srv.Close()
+
+	got, err := fetchDigest(srv.URL, "https://example.com/dir/image.tar.gz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "deadbeef" {
+		t.Fatalf("expected digest %q, got %q", "deadbeef", got)
+	}
+}
+
+func TestFetchDigestNoMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("deadbeef  other.tar.gz\n"))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchDigest(srv.URL, "https://example.com/dir/image.tar.gz"); err == nil {
+		t.Fatalf("expected error for unmatched basename")
+	}
+}
+
+func TestSHAValidateDownload(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "payload")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer f.Close()
+
+	const content = "the quick brown fox jumps over the lazy dog"
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("error writing temp file: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	correct := hex.EncodeToString(sum[:])
+
+	t.Run("matching digest passes", func(t *testing.T) {
+		s := SHA{Digest: Digest{Value: correct}}
+		if err := s.validateDownload("https://example.com/payload", f.Name()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("mismatched digest fails", func(t *testing.T) {
+		s := SHA{Digest: Digest{Value: "0000000000000000000000000000000000000000000000000000000000000000"}}
+		if err := s.validateDownload("https://example.com/payload", f.Name()); err == nil {
+			t.Fatalf("expected error for mismatched digest")
+		}
+	})
+
+	t.Run("alternate digest must also match", func(t *testing.T) {
+		s := SHA{
+			Digest:     Digest{Value: correct},
+			Alternates: []Digest{{Algorithm: "sha256", Value: "not-the-right-digest"}},
+		}
+		if err := s.validateDownload("https://example.com/payload", f.Name()); err == nil {
+			t.Fatalf("expected error for mismatched alternate digest")
+		}
+	})
+
+	t.Run("unsupported algorithm fails", func(t *testing.T) {
+		s := SHA{Digest: Digest{Algorithm: "md4", Value: correct}}
+		if err := s.validateDownload("https://example.com/payload", f.Name()); err == nil {
+			t.Fatalf("expected error for unsupported algorithm")
+		}
+	})
+
+	t.Run("no pinned or fetchable digest is a no-op", func(t *testing.T) {
+		s := SHA{}
+		if err := s.validateDownload("https://example.com/payload", f.Name()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestSHAValidateDownloadFetchesPrimaryFromURL(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "payload")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer f.Close()
+
+	const content = "payload contents"
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("error writing temp file: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	correct := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  payload.bin\n", correct)
+	}))
+	defer srv.Close()
+
+	s := SHA{URL: srv.URL}
+	if err := s.validateDownload(srv.URL+"/dir/payload.bin", f.Name()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
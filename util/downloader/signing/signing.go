@@ -0,0 +1,88 @@
+// Package signing implements detached-signature verification for downloaded
+// artifacts, modeled on Tailscale's distsign scheme: a small set of trusted
+// root keys certify short-lived signing keys, which in turn sign the
+// artifacts published with them.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Certificate certifies that SigningKey is authorized to sign artifacts, as
+// attested by RootSig, a signature over SigningKey produced by one of the
+// trusted root keys.
+type Certificate struct {
+	SigningKey ed25519.PublicKey `json:"signing_key"`
+	RootSig    []byte            `json:"root_sig"`
+}
+
+// Signature is the detached, on-disk signature accompanying a downloaded
+// artifact.
+type Signature struct {
+	Cert Certificate `json:"cert"`
+	Sig  []byte      `json:"sig"` // Cert.SigningKey's signature over the artifact's sha256 digest
+}
+
+// Verify checks that the detached signature at sigPath was produced by a
+// signing key certified by one of roots, and that it covers the sha256
+// digest of the artifact at artifactPath.
+func Verify(artifactPath, sigPath string, roots []ed25519.PublicKey) error {
+	raw, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("error reading signature file: %w", err)
+	}
+
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return fmt.Errorf("error parsing signature file: %w", err)
+	}
+
+	if err := verifyCert(sig.Cert, roots); err != nil {
+		return fmt.Errorf("error verifying signing key certificate: %w", err)
+	}
+
+	digest, err := sha256File(artifactPath)
+	if err != nil {
+		return fmt.Errorf("error hashing artifact: %w", err)
+	}
+
+	if !ed25519.Verify(sig.Cert.SigningKey, digest, sig.Sig) {
+		return fmt.Errorf("signature does not match artifact")
+	}
+
+	return nil
+}
+
+// verifyCert checks that cert.SigningKey is certified by one of roots.
+func verifyCert(cert Certificate, roots []ed25519.PublicKey) error {
+	if len(cert.SigningKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid signing key length %d", len(cert.SigningKey))
+	}
+
+	for _, root := range roots {
+		if ed25519.Verify(root, cert.SigningKey, cert.RootSig) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signing key is not certified by any trusted root")
+}
+
+func sha256File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
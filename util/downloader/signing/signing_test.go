@@ -0,0 +1,138 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	return pub, priv
+}
+
+// writeSignedArtifact writes content and a valid detached signature over it,
+// certified by rootPriv, into dir.
+func writeSignedArtifact(t *testing.T, dir string, content []byte, rootPriv ed25519.PrivateKey) (artifactPath, sigPath string) {
+	t.Helper()
+
+	signingPub, signingPriv := generateKey(t)
+	rootSig := ed25519.Sign(rootPriv, signingPub)
+
+	digest := sha256.Sum256(content)
+	artifactSig := ed25519.Sign(signingPriv, digest[:])
+
+	raw, err := json.Marshal(Signature{
+		Cert: Certificate{SigningKey: signingPub, RootSig: rootSig},
+		Sig:  artifactSig,
+	})
+	if err != nil {
+		t.Fatalf("error marshaling signature: %v", err)
+	}
+
+	artifactPath = filepath.Join(dir, "artifact.bin")
+	if err := os.WriteFile(artifactPath, content, 0644); err != nil {
+		t.Fatalf("error writing artifact: %v", err)
+	}
+
+	sigPath = artifactPath + ".sig"
+	if err := os.WriteFile(sigPath, raw, 0644); err != nil {
+		t.Fatalf("error writing signature file: %v", err)
+	}
+
+	return artifactPath, sigPath
+}
+
+func TestVerifyValidSignature(t *testing.T) {
+	dir := t.TempDir()
+	rootPub, rootPriv := generateKey(t)
+	artifactPath, sigPath := writeSignedArtifact(t, dir, []byte("hello world"), rootPriv)
+
+	if err := Verify(artifactPath, sigPath, []ed25519.PublicKey{rootPub}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyTamperedArtifact(t *testing.T) {
+	dir := t.TempDir()
+	rootPub, rootPriv := generateKey(t)
+	artifactPath, sigPath := writeSignedArtifact(t, dir, []byte("hello world"), rootPriv)
+
+	if err := os.WriteFile(artifactPath, []byte("tampered content"), 0644); err != nil {
+		t.Fatalf("error tampering artifact: %v", err)
+	}
+
+	if err := Verify(artifactPath, sigPath, []ed25519.PublicKey{rootPub}); err == nil {
+		t.Fatalf("expected error for tampered artifact")
+	}
+}
+
+func TestVerifyUntrustedRoot(t *testing.T) {
+	dir := t.TempDir()
+	_, rootPriv := generateKey(t)
+	otherRootPub, _ := generateKey(t) // an unrelated root, not the one that certified the signing key
+
+	artifactPath, sigPath := writeSignedArtifact(t, dir, []byte("hello world"), rootPriv)
+
+	if err := Verify(artifactPath, sigPath, []ed25519.PublicKey{otherRootPub}); err == nil {
+		t.Fatalf("expected error for signing key certified by an untrusted root")
+	}
+}
+
+func TestVerifyNoRoots(t *testing.T) {
+	dir := t.TempDir()
+	_, rootPriv := generateKey(t)
+	artifactPath, sigPath := writeSignedArtifact(t, dir, []byte("hello world"), rootPriv)
+
+	if err := Verify(artifactPath, sigPath, nil); err == nil {
+		t.Fatalf("expected error when no roots are trusted")
+	}
+}
+
+func TestVerifyMalformedSignatureFile(t *testing.T) {
+	dir := t.TempDir()
+	rootPub, _ := generateKey(t)
+
+	artifactPath := filepath.Join(dir, "artifact.bin")
+	if err := os.WriteFile(artifactPath, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("error writing artifact: %v", err)
+	}
+
+	sigPath := artifactPath + ".sig"
+	if err := os.WriteFile(sigPath, []byte("not json"), 0644); err != nil {
+		t.Fatalf("error writing signature file: %v", err)
+	}
+
+	if err := Verify(artifactPath, sigPath, []ed25519.PublicKey{rootPub}); err == nil {
+		t.Fatalf("expected error for malformed signature file")
+	}
+}
+
+func TestVerifyMissingSignatureFile(t *testing.T) {
+	dir := t.TempDir()
+	rootPub, _ := generateKey(t)
+
+	artifactPath := filepath.Join(dir, "artifact.bin")
+	if err := os.WriteFile(artifactPath, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("error writing artifact: %v", err)
+	}
+
+	if err := Verify(artifactPath, filepath.Join(dir, "missing.sig"), []ed25519.PublicKey{rootPub}); err == nil {
+		t.Fatalf("expected error for missing signature file")
+	}
+}
+
+func TestVerifyCertRejectsWrongLengthSigningKey(t *testing.T) {
+	cert := Certificate{SigningKey: []byte("too-short"), RootSig: []byte("irrelevant")}
+	if err := verifyCert(cert, nil); err == nil {
+		t.Fatalf("expected error for wrong-length signing key")
+	}
+}
@@ -18,6 +18,25 @@ func ClearLine() {
 	fmt.Print("\033[1A \033[2K \r")
 }
 
+// ClearLines clears the previous n lines of the terminal, e.g. to redraw a
+// multi-line progress display in place.
+func ClearLines(n int) {
+	if !isTerminal {
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		ClearLine()
+	}
+}
+
+// IsTerminal reports whether stdout is attached to a terminal. Callers use
+// this to decide between an interactive, redrawn progress display and
+// plain log lines suited to non-interactive output (e.g. CI logs).
+func IsTerminal() bool {
+	return isTerminal
+}
+
 // Progress returns a string of the progress
 func Progress(current, total int64) string {
 	if total <= 0 {